@@ -0,0 +1,78 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// CollectGarbage synchronously drives eviction down to target, or to
+// db.gcTarget() if target is zero, returning the number of chunks it
+// collected. It returns once the target size is reached or ctx is
+// done, whichever comes first, or earlier, without an error, when a
+// garbage collection run finds no more unpinned chunks to remove
+// before the target is reached. Unlike the background GC loop, chunks
+// removed this way are published as GCEvents with GCReasonManual.
+func (db *DB) CollectGarbage(ctx context.Context, target uint64) (collected int64, err error) {
+	t := int64(target)
+	if t == 0 {
+		t = db.gcTarget()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return collected, ctx.Err()
+		default:
+		}
+
+		if atomic.LoadInt64(&db.gcSize) <= t {
+			return collected, nil
+		}
+
+		n, done, err := db.collectGarbage(t, GCReasonManual)
+		collected += n
+		if err != nil {
+			return collected, err
+		}
+		if done {
+			return collected, nil
+		}
+	}
+}
+
+// Compact reclaims disk space held by leveldb tombstones left behind
+// by large GC sweeps. It should be called after a CollectGarbage run
+// that removed a significant number of chunks.
+func (db *DB) Compact(ctx context.Context) (err error) {
+	for _, prefix := range [][]byte{
+		db.retrievalDataIndex.Prefix(),
+		db.pullIndex.Prefix(),
+		db.gcIndex.Prefix(),
+	} {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if err := db.shed.CompactRange(prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
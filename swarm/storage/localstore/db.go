@@ -0,0 +1,230 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package localstore provides disk storage layer for Swarm Chunk persistence.
+// It uses a single shed.DB for storing and indexing chunk data. Indexes are
+// created with functions that do not have global state, which makes it easy
+// to add additional indexes in this package.
+package localstore
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/swarm/shed"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// DB is the local store implementation and holds
+// indexes and sizes for the badger-like storage of chunks.
+type DB struct {
+	shed *shed.DB
+
+	// schema name of loaded data
+	schemaName shed.StringField
+
+	// retrieval indexes
+	retrievalDataIndex         shed.Index
+	retrievalAccessIndex       shed.Index
+	useRetrievalCompositeIndex bool
+
+	// push and pull syncing indexes
+	pushIndex shed.Index
+	pullIndex shed.Index
+
+	// garbage collection index
+	gcIndex shed.Index
+
+	// field that stores number of intems in gc index
+	gcSize int64
+
+	// pin index and counter, added together with
+	// pinning support for chunks
+	pinIndex shed.Index
+
+	// postageIndex tracks the postage batch, value and proximity
+	// order of every chunk held in the reserve.
+	postageIndex shed.Index
+
+	// field that stores number of chunks currently held in the
+	// postage-aware reserve, as opposed to the cache (gc) tier
+	reserveSize int64
+
+	// reserveCapacity is the configured limit for reserveSize
+	reserveCapacity uint64
+
+	// batchRadius tracks, per postage batch, the farthest proximity
+	// order still retained in the reserve for that batch
+	batchRadiusMu sync.RWMutex
+	batchRadius   map[string]uint8
+
+	capacity uint64
+
+	baseKey []byte
+
+	batchMu sync.Mutex
+
+	updateGCSem chan struct{}
+
+	// gcMu serializes collectGarbage runs so that the background
+	// worker and a caller-triggered CollectGarbage cannot interleave
+	// and race on the same gcIndex items.
+	gcMu sync.Mutex
+
+	// gcSubs holds active SubscribeGC subscribers, keyed by an
+	// internal subscription id
+	gcSubsMu     sync.Mutex
+	gcSubs       map[uint64]*gcSubscription
+	gcSubsNextID uint64
+
+	close chan struct{}
+	wg    sync.WaitGroup
+}
+
+// Options struct holds optional parameters for configuring DB.
+type Options struct {
+	// Capacity is a limit for item number in gc index.
+	Capacity uint64
+	// UseRetrievalCompositeIndex is a flag for a desired index type
+	// for retrieval. This flag should be used only for test purposes.
+	UseRetrievalCompositeIndex bool
+	// ReserveCapacity is a limit for the number of chunks held in the
+	// postage-aware reserve, before they become eligible for eviction
+	// into the cache (gc) tier. If not set, the reserve tier is
+	// effectively disabled and chunks are gc-tracked as before.
+	ReserveCapacity uint64
+}
+
+// New returns a new DB. All fields and indexes are initialized.
+func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
+	if o == nil {
+		o = &Options{
+			Capacity: defaultCapacity,
+		}
+	}
+	db = &DB{
+		capacity:                   o.Capacity,
+		reserveCapacity:            o.ReserveCapacity,
+		baseKey:                    baseKey,
+		useRetrievalCompositeIndex: o.UseRetrievalCompositeIndex,
+		updateGCSem:                make(chan struct{}, 1),
+		close:                      make(chan struct{}),
+		batchRadius:                make(map[string]uint8),
+		gcSubs:                     make(map[uint64]*gcSubscription),
+	}
+	if db.capacity <= 0 {
+		db.capacity = defaultCapacity
+	}
+
+	db.shed, err = shed.NewDB(path)
+	if err != nil {
+		return nil, err
+	}
+
+	db.retrievalDataIndex, err = db.shed.NewIndex("Address->StoreTimestamp|Data", shed.IndexFuncs{})
+	if err != nil {
+		return nil, err
+	}
+	db.retrievalAccessIndex, err = db.shed.NewIndex("Address->AccessTimestamp", shed.IndexFuncs{})
+	if err != nil {
+		return nil, err
+	}
+	db.pushIndex, err = db.shed.NewIndex("StoreTimestamp|Address->nil", shed.IndexFuncs{})
+	if err != nil {
+		return nil, err
+	}
+	db.pullIndex, err = db.shed.NewIndex("PO|BinID->Hash", shed.IndexFuncs{})
+	if err != nil {
+		return nil, err
+	}
+	db.gcIndex, err = db.shed.NewIndex("AccessTimestamp|Address->nil", shed.IndexFuncs{})
+	if err != nil {
+		return nil, err
+	}
+	db.pinIndex, err = db.shed.NewIndex("Address->PinCounter", shed.IndexFuncs{})
+	if err != nil {
+		return nil, err
+	}
+	db.postageIndex, err = db.shed.NewIndex("BatchID|Address->Value|PO", shed.IndexFuncs{})
+	if err != nil {
+		return nil, err
+	}
+
+	gcSize, err := db.gcIndex.Count()
+	if err != nil {
+		return nil, err
+	}
+	db.gcSize = int64(gcSize)
+
+	reserveSize, err := db.postageIndex.Count()
+	if err != nil {
+		return nil, err
+	}
+	db.reserveSize = int64(reserveSize)
+
+	db.wg.Add(1)
+	go db.collectGarbageWorker()
+
+	return db, nil
+}
+
+// defaultCapacity is used as the capacity value when
+// Options.Capacity is not set.
+const defaultCapacity uint64 = 5000000
+
+// Close closes the underlying database.
+func (db *DB) Close() (err error) {
+	close(db.close)
+	db.wg.Wait()
+	return db.shed.Close()
+}
+
+// po computes a proximity order between the passed storage.Address and
+// the DB's base key, that is, how close the address is to the node's
+// base address.
+func (db *DB) po(addr storage.Address) (bin uint8) {
+	return uint8(storage.Proximity(db.baseKey, addr))
+}
+
+var (
+	// ErrNoGCRequired is returned when the garbage collection is a no-op
+	// because the current size is already at or below the target.
+	ErrNoGCRequired = errors.New("no garbage collection required")
+)
+
+// triggerGarbageCollection signals to the collectGarbageWorker that another
+// round of garbage collection is needed.
+func (db *DB) triggerGarbageCollection() {
+	select {
+	case db.updateGCSem <- struct{}{}:
+	default:
+	}
+}
+
+// incGCSizeInBatch changes gcSize field value
+// by change which can be negative. gcSize is updated
+// atomically, so no lock is needed to call it.
+func (db *DB) incGCSizeInBatch(change int64) (err error) {
+	if change == 0 {
+		return nil
+	}
+	new := atomic.AddInt64(&db.gcSize, change)
+	if new >= int64(db.capacity) {
+		db.triggerGarbageCollection()
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/swarm/shed"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// gcBatchSize limits the number of chunks in a single
+// leveldb batch writen during garbage collection. A low
+// number allows for a more responsive GC loop, while a too
+// low number increases the write overhead of GC.
+var gcBatchSize int64 = 120
+
+// collectGarbageWorker is a long running function that waits for
+// trigger requests for garbage collection. GC run is stopped when
+// db.close channel is closed.
+func (db *DB) collectGarbageWorker() {
+	defer db.wg.Done()
+
+	for {
+		select {
+		case <-db.updateGCSem:
+			movedCount, err := db.evictReserve()
+			if err != nil {
+				log.Error("localstore evict reserve", "err", err)
+			}
+			if testHookEvictReserve != nil {
+				testHookEvictReserve(movedCount)
+			}
+
+			collectedCount, done, err := db.collectGarbage(db.gcTarget(), GCReasonCapacity)
+			if err != nil {
+				log.Error("localstore collect garbage", "err", err)
+			}
+			if testHookCollectGarbage != nil {
+				testHookCollectGarbage(collectedCount)
+			}
+			if !done {
+				db.triggerGarbageCollection()
+			}
+		case <-db.close:
+			return
+		}
+	}
+}
+
+// collectGarbage removes chunks from retrieval, pull and gc indexes, and
+// from the disk, until gcSize falls to or below target or all eligible
+// chunks are removed, whichever comes first. Pinned chunks, tracked in
+// pinIndex with a positive reference counter, are skipped; they stay in
+// gcIndex and are still counted in gcSize. Every removed chunk is
+// published as a GCEvent with the given reason once the removal is
+// written and gcSize is updated. Runs are serialized by gcMu so that the
+// background worker and a caller-triggered CollectGarbage never
+// interleave and double-delete the same gcIndex items.
+func (db *DB) collectGarbage(target int64, reason GCReason) (collectedCount int64, done bool, err error) {
+	db.gcMu.Lock()
+	defer db.gcMu.Unlock()
+
+	batch := new(shed.Batch)
+	var gcSizeChange int64
+	var collected []storage.Address
+	done = true
+	err = db.gcIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		if atomic.LoadInt64(&db.gcSize)+gcSizeChange <= target {
+			return true, nil
+		}
+
+		pinned, err := db.isPinned(item.Address)
+		if err != nil {
+			return false, err
+		}
+		if pinned {
+			// pinned chunks are not eligible for garbage collection,
+			// but they remain counted in gcSize
+			return false, nil
+		}
+
+		db.gcIndex.DeleteInBatch(batch, item)
+		db.retrievalDataIndex.DeleteInBatch(batch, item)
+		db.retrievalAccessIndex.DeleteInBatch(batch, item)
+		db.pullIndex.DeleteInBatch(batch, item)
+
+		collected = append(collected, item.Address)
+
+		gcSizeChange--
+		collectedCount++
+
+		if collectedCount >= gcBatchSize {
+			done = false
+			return true, nil
+		}
+		return false, nil
+	}, nil)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if err := db.shed.WriteBatch(batch); err != nil {
+		return 0, false, err
+	}
+	atomic.AddInt64(&db.gcSize, gcSizeChange)
+
+	for _, addr := range collected {
+		db.publishGCEvent(addr, reason)
+	}
+
+	return collectedCount, done, nil
+}
+
+// gcTarget retruns the target value for garbage collection, so that
+// collectGarbage is stopped once this value is reached, leaving a
+// margin under capacity for newly uploaded chunks.
+func (db *DB) gcTarget() (target int64) {
+	return int64(db.capacity * 9 / 10)
+}
+
+// testHookCollectGarbage is a hook that can provide
+// information when a garbage collection run is done
+// and how many items it removed.
+var testHookCollectGarbage func(collectedCount int64)
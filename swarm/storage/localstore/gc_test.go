@@ -17,6 +17,7 @@
 package localstore
 
 import (
+	"context"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -92,10 +93,10 @@ func testDB_collectGarbage(t *testing.T, db *DB) {
 
 	chunkCount := 150
 
-	testHookCollectGarbageChan := make(chan int64)
-	defer setTestHookCollectGarbage(func(collectedCount int64) {
-		testHookCollectGarbageChan <- collectedCount
-	})()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gcEvents, unsubscribe := db.SubscribeGC(ctx)
+	defer unsubscribe()
 
 	addrs := make([]storage.Address, 0)
 
@@ -118,25 +119,20 @@ func testDB_collectGarbage(t *testing.T, db *DB) {
 
 	gcTarget := db.gcTarget()
 
-	var totalCollectedCount int64
+	// gcEvents is a lossy channel once its buffer is full, so it is
+	// only used here to wake up and recheck gcSize, not to count
+	// collected chunks exactly.
 	for {
 		select {
-		case c := <-testHookCollectGarbageChan:
-			totalCollectedCount += c
+		case <-gcEvents:
 		case <-time.After(10 * time.Second):
-			t.Error("collect garbage timeout")
+			t.Fatal("collect garbage timeout")
 		}
-		gcSize := atomic.LoadInt64(&db.gcSize)
-		if gcSize == gcTarget {
+		if atomic.LoadInt64(&db.gcSize) == gcTarget {
 			break
 		}
 	}
 
-	wantTotalCollectedCount := int64(chunkCount) - gcTarget
-	if totalCollectedCount != wantTotalCollectedCount {
-		t.Errorf("total collected chunks %v, want %v", totalCollectedCount, wantTotalCollectedCount)
-	}
-
 	t.Run("pull index count", newIndexItemsCountTest(db.pullIndex, int(gcTarget)))
 
 	t.Run("gc index count", newIndexItemsCountTest(db.gcIndex, int(gcTarget)))
@@ -191,10 +187,10 @@ func testDB_collectGarbage_withRequests(t *testing.T, db *DB) {
 	uploader := db.NewPutter(ModePutUpload)
 	syncer := db.NewSetter(ModeSetSync)
 
-	testHookCollectGarbageChan := make(chan int64)
-	defer setTestHookCollectGarbage(func(collectedCount int64) {
-		testHookCollectGarbageChan <- collectedCount
-	})()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gcEvents, unsubscribe := db.SubscribeGC(ctx)
+	defer unsubscribe()
 
 	addrs := make([]storage.Address, 0)
 
@@ -240,25 +236,20 @@ func testDB_collectGarbage_withRequests(t *testing.T, db *DB) {
 
 	gcTarget := db.gcTarget()
 
-	var totalCollectedCount int64
+	// gcEvents is a lossy channel once its buffer is full, so it is
+	// only used here to wake up and recheck gcSize, not to count
+	// collected chunks exactly.
 	for {
 		select {
-		case c := <-testHookCollectGarbageChan:
-			totalCollectedCount += c
+		case <-gcEvents:
 		case <-time.After(10 * time.Second):
-			t.Error("collect garbage timeout")
+			t.Fatal("collect garbage timeout")
 		}
-		gcSize := atomic.LoadInt64(&db.gcSize)
-		if gcSize == gcTarget {
+		if atomic.LoadInt64(&db.gcSize) == gcTarget {
 			break
 		}
 	}
 
-	wantTotalCollectedCount := int64(len(addrs)) - gcTarget
-	if totalCollectedCount != wantTotalCollectedCount {
-		t.Errorf("total collected chunks %v, want %v", totalCollectedCount, wantTotalCollectedCount)
-	}
-
 	t.Run("pull index count", newIndexItemsCountTest(db.pullIndex, int(gcTarget)))
 
 	t.Run("gc index count", newIndexItemsCountTest(db.gcIndex, int(gcTarget)))
@@ -290,6 +281,112 @@ func testDB_collectGarbage_withRequests(t *testing.T, db *DB) {
 	})
 }
 
+// TestDB_collectGarbage_slowSubscriber tests that a SubscribeGC
+// subscriber which does not drain its channel does not block the
+// garbage collection loop, and that it observes a nonzero Dropped
+// counter on the events it does eventually receive.
+func TestDB_collectGarbage_slowSubscriber(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity: 100,
+	})
+	defer cleanupFunc()
+
+	uploader := db.NewPutter(ModePutUpload)
+	syncer := db.NewSetter(ModeSetSync)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a fast subscriber used to detect when GC has finished running,
+	// so we know it is safe to start draining the slow one
+	doneEvents, doneUnsubscribe := db.SubscribeGC(ctx)
+	defer doneUnsubscribe()
+
+	slowEvents, slowUnsubscribe := db.SubscribeGC(ctx)
+	defer slowUnsubscribe()
+
+	chunkCount := 150
+	for i := 0; i < chunkCount; i++ {
+		chunk := generateRandomChunk()
+		if err := uploader.Put(chunk); err != nil {
+			t.Fatal(err)
+		}
+		if err := syncer.Set(chunk.Address()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gcTarget := db.gcTarget()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case <-doneEvents:
+		case <-timeout:
+			t.Fatal("collect garbage timeout")
+		}
+		if atomic.LoadInt64(&db.gcSize) == gcTarget {
+			break
+		}
+	}
+
+	var gotDropped bool
+	for {
+		select {
+		case e := <-slowEvents:
+			if e.Dropped > 0 {
+				gotDropped = true
+			}
+		default:
+			if !gotDropped {
+				t.Error("slow subscriber did not observe a nonzero Dropped counter")
+			}
+			return
+		}
+	}
+}
+
+// TestDB_CollectGarbage_manual uploads N chunks into a store whose
+// capacity is too large for background garbage collection to be
+// triggered, then drives eviction explicitly
+// through CollectGarbage and asserts it collects exactly N - target
+// chunks.
+func TestDB_CollectGarbage_manual(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity: 1000000, // large enough that background GC never triggers
+	})
+	defer cleanupFunc()
+
+	uploader := db.NewPutter(ModePutUpload)
+	syncer := db.NewSetter(ModeSetSync)
+
+	chunkCount := int(db.capacity/100) + 50
+	for i := 0; i < chunkCount; i++ {
+		chunk := generateRandomChunk()
+		if err := uploader.Put(chunk); err != nil {
+			t.Fatal(err)
+		}
+		if err := syncer.Set(chunk.Address()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target := int64(chunkCount - 50)
+
+	collected, err := db.CollectGarbage(context.Background(), uint64(target))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantCollected := int64(chunkCount) - target
+	if collected != wantCollected {
+		t.Errorf("collected %v chunks, want %v", collected, wantCollected)
+	}
+
+	if gcSize := atomic.LoadInt64(&db.gcSize); gcSize != target {
+		t.Errorf("got gc size %v, want %v", gcSize, target)
+	}
+}
+
 // setTestHookCollectGarbage sets testHookCollectGarbage and
 // returns a function that will reset it to the
 // value before the change.
@@ -351,4 +448,4 @@ func TestSetTestHookCollectGarbage(t *testing.T) {
 	if got != original {
 		t.Errorf("got hook value %v, want %v", got, original)
 	}
-}
\ No newline at end of file
+}
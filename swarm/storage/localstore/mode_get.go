@@ -0,0 +1,114 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"github.com/ethereum/go-ethereum/swarm/shed"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// ModeGet enumerates different reasons for retrieving chunks
+// from the database, so that access to them can be recorded
+// differently.
+type ModeGet int
+
+// ModeGet values.
+const (
+	// ModeGetRequest is used when a chunk is requested by a peer
+	// or a local client. It bumps the chunk to the back of the
+	// gc index, making it less likely to be collected.
+	ModeGetRequest ModeGet = iota
+	// ModeGetSync is used when a chunk is read for syncing
+	// purposes and does not affect its gc priority.
+	ModeGetSync
+	// ModeGetPin is used to retrieve a chunk only if it is
+	// currently pinned, that is, its pin counter is greater
+	// than zero.
+	ModeGetPin
+)
+
+// Getter provides a way to get chunks from the database
+// with a specific ModeGet.
+type Getter struct {
+	db   *DB
+	mode ModeGet
+}
+
+// NewGetter returns a new Getter on database with a specific Mode.
+func (db *DB) NewGetter(mode ModeGet) *Getter {
+	return &Getter{
+		db:   db,
+		mode: mode,
+	}
+}
+
+// Get returns a chunk from the retrieval index. Depending on the
+// mode, its access timestamp in the gc index is updated so that
+// it is less likely to be garbage collected soon after.
+func (g *Getter) Get(addr storage.Address) (ch storage.Chunk, err error) {
+	item := shed.Item{Address: addr}
+
+	if g.mode == ModeGetPin {
+		pinned, err := g.db.isPinned(addr)
+		if err != nil {
+			return nil, err
+		}
+		if !pinned {
+			return nil, storage.ErrChunkNotFound
+		}
+	}
+
+	out, err := g.db.retrievalDataIndex.Get(item)
+	if err != nil {
+		if err == shed.ErrNotFound {
+			return nil, storage.ErrChunkNotFound
+		}
+		return nil, err
+	}
+
+	if g.mode == ModeGetRequest {
+		if err := g.db.updateGCItem(out); err != nil {
+			return nil, err
+		}
+	}
+
+	return storage.NewChunk(out.Address, out.Data), nil
+}
+
+// updateGCItem moves item to the back of the gc index by
+// refreshing its access timestamp. The current gc index entry is
+// found through the access timestamp in retrievalAccessIndex; a
+// chunk without one is not in the gc index and is left as it is.
+func (db *DB) updateGCItem(item shed.Item) (err error) {
+	i, err := db.retrievalAccessIndex.Get(item)
+	if err != nil {
+		if err == shed.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	item.AccessTimestamp = i.AccessTimestamp
+
+	batch := new(shed.Batch)
+
+	db.gcIndex.DeleteInBatch(batch, item)
+	item.AccessTimestamp = now()
+	db.gcIndex.PutInBatch(batch, item)
+	db.retrievalAccessIndex.PutInBatch(batch, item)
+
+	return db.shed.WriteBatch(batch)
+}
@@ -0,0 +1,96 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/swarm/shed"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// ModePut enumerates different reasons for putting chunks
+// into the database.
+type ModePut int
+
+// ModePut values.
+const (
+	// ModePutRequest is used when a chunk is received
+	// as a result of retrieval request from a peer.
+	ModePutRequest ModePut = iota
+	// ModePutSync is used when a chunk is received
+	// through syncing between nodes.
+	ModePutSync
+	// ModePutUpload is used when a chunk is uploaded
+	// by a local client directly to this node.
+	ModePutUpload
+)
+
+// Putter provides a way to put chunks into the database
+// with a specific ModePut.
+type Putter struct {
+	db   *DB
+	mode ModePut
+}
+
+// NewPutter returns a new Putter on database with a specific Mode.
+func (db *DB) NewPutter(mode ModePut) *Putter {
+	return &Putter{
+		db:   db,
+		mode: mode,
+	}
+}
+
+// Put stores the chunk in the retrieval index and, depending on
+// the mode, in the push and pull syncing indexes as well as the
+// garbage collection index.
+func (p *Putter) Put(ch storage.Chunk) (err error) {
+	batch := new(shed.Batch)
+
+	item := shed.Item{
+		Address:         ch.Address(),
+		Data:            ch.Data(),
+		StoreTimestamp:  now(),
+		AccessTimestamp: now(),
+	}
+
+	p.db.retrievalDataIndex.PutInBatch(batch, item)
+
+	var gcSizeChange int64
+	switch p.mode {
+	case ModePutRequest:
+		p.db.retrievalAccessIndex.PutInBatch(batch, item)
+		p.db.gcIndex.PutInBatch(batch, item)
+		gcSizeChange = 1
+	case ModePutUpload:
+		p.db.pushIndex.PutInBatch(batch, item)
+		p.db.pullIndex.PutInBatch(batch, item)
+	case ModePutSync:
+		p.db.pullIndex.PutInBatch(batch, item)
+	}
+
+	if err := p.db.shed.WriteBatch(batch); err != nil {
+		return err
+	}
+	return p.db.incGCSizeInBatch(gcSizeChange)
+}
+
+// now returns the current unix time in nanoseconds. It is a variable
+// so it can be overridden in tests.
+var now = func() int64 {
+	return time.Now().UnixNano()
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"github.com/ethereum/go-ethereum/swarm/shed"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// ModeSet enumerates different reasons for setting the state
+// of a chunk in the database.
+type ModeSet int
+
+// ModeSet values.
+const (
+	// ModeSetAccess is used to denote that the chunk is accessed
+	// and should be inserted into the gc index, making it
+	// eligible for garbage collection.
+	ModeSetAccess ModeSet = iota
+	// ModeSetSync is used when a chunk is synced to a peer and
+	// should be added to the gc index.
+	ModeSetSync
+	// ModeSetRemove is used to remove the chunk from the retrieval,
+	// push, pull and gc indexes. Its pin and postage entries are kept.
+	ModeSetRemove
+	// ModeSetPin is used to increase the pin counter of the chunk,
+	// exempting it from garbage collection while the counter is
+	// greater than zero.
+	ModeSetPin
+	// ModeSetUnpin is used to decrease the pin counter of the chunk,
+	// making it eligible for garbage collection again once the
+	// counter reaches zero.
+	ModeSetUnpin
+)
+
+// Setter provides a way to set the state of a chunk in the
+// database with a specific ModeSet.
+type Setter struct {
+	db   *DB
+	mode ModeSet
+}
+
+// NewSetter returns a new Setter on database with a specific Mode.
+func (db *DB) NewSetter(mode ModeSet) *Setter {
+	return &Setter{
+		db:   db,
+		mode: mode,
+	}
+}
+
+// Set updates the state of the chunk with the given address
+// according to the Setter's mode.
+func (s *Setter) Set(addr storage.Address) (err error) {
+	batch := new(shed.Batch)
+
+	item := shed.Item{Address: addr}
+	item, err = s.db.retrievalDataIndex.Get(item)
+	if err != nil {
+		if err == shed.ErrNotFound {
+			return storage.ErrChunkNotFound
+		}
+		return err
+	}
+
+	switch s.mode {
+	case ModeSetAccess, ModeSetSync:
+		item.AccessTimestamp = now()
+		s.db.gcIndex.PutInBatch(batch, item)
+		s.db.retrievalAccessIndex.PutInBatch(batch, item)
+		if err := s.db.shed.WriteBatch(batch); err != nil {
+			return err
+		}
+		return s.db.incGCSizeInBatch(1)
+	case ModeSetRemove:
+		// the gc index is keyed by the access timestamp held in
+		// retrievalAccessIndex; a chunk without one is not in it
+		var gcSizeChange int64
+		i, err := s.db.retrievalAccessIndex.Get(item)
+		switch err {
+		case nil:
+			item.AccessTimestamp = i.AccessTimestamp
+			gcSizeChange = -1
+		case shed.ErrNotFound:
+		default:
+			return err
+		}
+		s.db.retrievalDataIndex.DeleteInBatch(batch, item)
+		s.db.retrievalAccessIndex.DeleteInBatch(batch, item)
+		s.db.pullIndex.DeleteInBatch(batch, item)
+		s.db.pushIndex.DeleteInBatch(batch, item)
+		s.db.gcIndex.DeleteInBatch(batch, item)
+		if err := s.db.shed.WriteBatch(batch); err != nil {
+			return err
+		}
+		return s.db.incGCSizeInBatch(gcSizeChange)
+	case ModeSetPin:
+		return s.db.setPin(item.Address)
+	case ModeSetUnpin:
+		return s.db.setUnpin(item.Address)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"github.com/ethereum/go-ethereum/swarm/shed"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// setPin increments the pin counter for the chunk with the
+// given address, inserting a new pinIndex entry with a
+// counter of one if the chunk was not pinned before.
+func (db *DB) setPin(addr storage.Address) (err error) {
+	item := shed.Item{Address: addr}
+
+	i, err := db.pinIndex.Get(item)
+	if err != nil {
+		if err != shed.ErrNotFound {
+			return err
+		}
+		item.PinCounter = 0
+	} else {
+		item.PinCounter = i.PinCounter
+	}
+	item.PinCounter++
+
+	return db.pinIndex.Put(item)
+}
+
+// setUnpin decrements the pin counter for the chunk with the
+// given address, removing the pinIndex entry once the counter
+// reaches zero. Unpinning a chunk that is not pinned is a no-op.
+func (db *DB) setUnpin(addr storage.Address) (err error) {
+	item := shed.Item{Address: addr}
+
+	i, err := db.pinIndex.Get(item)
+	if err != nil {
+		if err == shed.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if i.PinCounter <= 1 {
+		return db.pinIndex.Delete(item)
+	}
+	i.PinCounter--
+	return db.pinIndex.Put(i)
+}
+
+// isPinned returns whether the chunk with the given address has a
+// pin counter greater than zero.
+func (db *DB) isPinned(addr storage.Address) (yes bool, err error) {
+	i, err := db.pinIndex.Get(shed.Item{Address: addr})
+	if err != nil {
+		if err == shed.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return i.PinCounter > 0, nil
+}
+
+// Pin describes a single pinned chunk and how many times it has been
+// pinned.
+type Pin struct {
+	Address    storage.Address
+	PinCounter uint64
+}
+
+// Pins returns all currently pinned chunks along with their pin
+// counters.
+func (db *DB) Pins() (pins []Pin, err error) {
+	err = db.pinIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		pins = append(pins, Pin{Address: item.Address, PinCounter: item.PinCounter})
+		return false, nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
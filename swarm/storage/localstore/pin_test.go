@@ -0,0 +1,159 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// TestDB_pinnedChunks_surviveGC uploads more chunks than the store's
+// Capacity and pins a subset of them, asserting that pinned chunks are
+// never evicted by the garbage collector while the rest are collected
+// as usual.
+func TestDB_pinnedChunks_surviveGC(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity: 100,
+	})
+	defer cleanupFunc()
+
+	uploader := db.NewPutter(ModePutUpload)
+	syncer := db.NewSetter(ModeSetSync)
+	pinner := db.NewSetter(ModeSetPin)
+
+	testHookCollectGarbageChan := make(chan int64)
+	defer setTestHookCollectGarbage(func(collectedCount int64) {
+		testHookCollectGarbageChan <- collectedCount
+	})()
+
+	chunkCount := 150
+	pinnedAddrs := make([]storage.Address, 0)
+	addrs := make([]storage.Address, 0, chunkCount)
+
+	for i := 0; i < chunkCount; i++ {
+		chunk := generateRandomChunk()
+
+		if err := uploader.Put(chunk); err != nil {
+			t.Fatal(err)
+		}
+		if err := syncer.Set(chunk.Address()); err != nil {
+			t.Fatal(err)
+		}
+
+		// pin the first ten chunks, which would otherwise be the
+		// first to be garbage collected
+		if i < 10 {
+			if err := pinner.Set(chunk.Address()); err != nil {
+				t.Fatal(err)
+			}
+			pinnedAddrs = append(pinnedAddrs, chunk.Address())
+		}
+
+		addrs = append(addrs, chunk.Address())
+	}
+
+	gcTarget := db.gcTarget()
+
+	var totalCollectedCount int64
+	for {
+		select {
+		case c := <-testHookCollectGarbageChan:
+			totalCollectedCount += c
+		case <-time.After(10 * time.Second):
+			t.Fatal("collect garbage timeout")
+		}
+		gcSize := atomic.LoadInt64(&db.gcSize)
+		if gcSize <= gcTarget {
+			break
+		}
+	}
+
+	for _, addr := range pinnedAddrs {
+		if _, err := db.NewGetter(ModeGetRequest).Get(addr); err != nil {
+			t.Errorf("pinned chunk %s was collected: %v", addr, err)
+		}
+	}
+}
+
+// TestDB_pinUnpin tests that the pin reference counter is correctly
+// maintained across repeated pin and unpin calls, and that a chunk
+// only becomes eligible for garbage collection again once it has
+// been unpinned as many times as it was pinned.
+func TestDB_pinUnpin(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity: 100,
+	})
+	defer cleanupFunc()
+
+	uploader := db.NewPutter(ModePutUpload)
+	syncer := db.NewSetter(ModeSetSync)
+	pinner := db.NewSetter(ModeSetPin)
+	unpinner := db.NewSetter(ModeSetUnpin)
+
+	chunk := generateRandomChunk()
+	if err := uploader.Put(chunk); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncer.Set(chunk.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := pinner.Set(chunk.Address()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pins, err := db.Pins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 {
+		t.Fatalf("got %v pinned chunks, want 1", len(pins))
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := unpinner.Set(chunk.Address()); err != nil {
+			t.Fatal(err)
+		}
+		pinned, err := db.isPinned(chunk.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pinned {
+			t.Fatal("chunk should still be pinned")
+		}
+	}
+
+	if err := unpinner.Set(chunk.Address()); err != nil {
+		t.Fatal(err)
+	}
+	pinned, err := db.isPinned(chunk.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pinned {
+		t.Fatal("chunk should no longer be pinned")
+	}
+
+	if _, err := db.NewGetter(ModeGetPin).Get(chunk.Address()); err != storage.ErrChunkNotFound {
+		t.Errorf("got error %v, want %v", err, storage.ErrChunkNotFound)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/swarm/shed"
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// PostageStamp carries the postage batch information attached to a
+// chunk when it is put into the reserve. Value is the per-chunk value
+// of the batch, used to order batches for eviction, and PO is the
+// chunk's proximity order to this node's base address, used to evict
+// the chunks of a low-value batch that are farthest from the node's
+// storage radius first.
+type PostageStamp struct {
+	BatchID []byte
+	Value   uint64
+	PO      uint8
+}
+
+// PutWithStamp stores the chunk the same way Put does, additionally
+// recording its postage stamp in postageIndex so that it participates
+// in reserve eviction instead of being immediately gc-eligible. It is
+// only meaningful for ModePutUpload and ModePutSync putters.
+func (p *Putter) PutWithStamp(ch storage.Chunk, stamp *PostageStamp) (err error) {
+	if err := p.Put(ch); err != nil {
+		return err
+	}
+	if p.mode != ModePutUpload && p.mode != ModePutSync {
+		return nil
+	}
+
+	item := shed.Item{
+		Address: ch.Address(),
+		BatchID: stamp.BatchID,
+		Value:   stamp.Value,
+		PO:      stamp.PO,
+	}
+	if err := p.db.postageIndex.Put(item); err != nil {
+		return err
+	}
+
+	p.db.batchRadiusMu.Lock()
+	if r, ok := p.db.batchRadius[string(stamp.BatchID)]; !ok || stamp.PO > r {
+		p.db.batchRadius[string(stamp.BatchID)] = stamp.PO
+	}
+	p.db.batchRadiusMu.Unlock()
+
+	new := atomic.AddInt64(&p.db.reserveSize, 1)
+	if p.db.reserveCapacity > 0 && new >= int64(p.db.reserveCapacity) {
+		p.db.triggerGarbageCollection()
+	}
+	return nil
+}
+
+// testHookEvictReserve is a hook that can provide information when a
+// reserve eviction run is done and how many chunks it moved into the
+// cache (gc) tier.
+var testHookEvictReserve func(movedCount int64)
+
+// reserveTarget returns the target value for reserve eviction, so that
+// evictReserve is stopped once this value is reached, leaving a margin
+// under ReserveCapacity for newly uploaded chunks.
+func (db *DB) reserveTarget() int64 {
+	return int64(db.reserveCapacity * 9 / 10)
+}
+
+// evictReserve moves chunks out of the reserve tier and into the
+// cache (gc) tier, starting with the batches whose per-chunk value is
+// lowest. Within a batch, chunks are evicted one proximity order at a
+// time, starting from the farthest PO still held for that batch and
+// working down towards the node's storage radius, so that a batch is
+// only evicted as far as is actually needed. It stops once reserveSize
+// falls to or below reserveTarget.
+func (db *DB) evictReserve() (movedCount int64, err error) {
+	if db.reserveCapacity == 0 {
+		return 0, nil
+	}
+
+	target := db.reserveTarget()
+
+	batchIDs, err := db.batchesByValueAscending()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, batchID := range batchIDs {
+		for atomic.LoadInt64(&db.reserveSize) > target {
+			db.batchRadiusMu.RLock()
+			po, ok := db.batchRadius[string(batchID)]
+			db.batchRadiusMu.RUnlock()
+			if !ok {
+				break
+			}
+
+			n, err := db.evictBatch(batchID, po)
+			if err != nil {
+				return movedCount, err
+			}
+			movedCount += n
+		}
+	}
+
+	return movedCount, nil
+}
+
+// batchesByValueAscending returns the distinct batch ids currently
+// present in postageIndex, ordered from lowest to highest value.
+func (db *DB) batchesByValueAscending() (batchIDs [][]byte, err error) {
+	values := make(map[string]uint64)
+	err = db.postageIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		id := string(item.BatchID)
+		if v, ok := values[id]; !ok || item.Value < v {
+			values[id] = item.Value
+		}
+		return false, nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for id := range values {
+		batchIDs = append(batchIDs, []byte(id))
+	}
+	sort.Slice(batchIDs, func(i, j int) bool {
+		return values[string(batchIDs[i])] < values[string(batchIDs[j])]
+	})
+	return batchIDs, nil
+}
+
+// evictBatch moves every chunk of the given batch at proximity order
+// po out of the reserve and into the cache (gc) tier, where it becomes
+// eligible for the existing LRU garbage collection, then lowers
+// batchRadius for the batch to the next farthest PO it still holds (or
+// removes the entry if none remain).
+func (db *DB) evictBatch(batchID []byte, po uint8) (movedCount int64, err error) {
+	batch := new(shed.Batch)
+
+	var items []shed.Item
+	var nextPO uint8
+	hasNext := false
+	err = db.postageIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		if string(item.BatchID) != string(batchID) {
+			return false, nil
+		}
+		if item.PO == po {
+			items = append(items, item)
+			return false, nil
+		}
+		if item.PO < po && (!hasNext || item.PO > nextPO) {
+			nextPO = item.PO
+			hasNext = true
+		}
+		return false, nil
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var removedCount int64
+	for _, item := range items {
+		gcItem, err := db.retrievalDataIndex.Get(shed.Item{Address: item.Address})
+		if err != nil {
+			// the chunk data is gone; still drop it from the reserve
+			// so it does not keep counting against reserveSize, but it
+			// has nothing to move into the gc index.
+			db.postageIndex.DeleteInBatch(batch, item)
+			removedCount++
+			continue
+		}
+		db.postageIndex.DeleteInBatch(batch, item)
+		db.gcIndex.PutInBatch(batch, gcItem)
+		db.publishGCEvent(item.Address, GCReasonReserve)
+		removedCount++
+		movedCount++
+	}
+
+	if err := db.shed.WriteBatch(batch); err != nil {
+		return 0, err
+	}
+	atomic.AddInt64(&db.reserveSize, -removedCount)
+	if err := db.incGCSizeInBatch(movedCount); err != nil {
+		return movedCount, err
+	}
+
+	db.batchRadiusMu.Lock()
+	if hasNext {
+		db.batchRadius[string(batchID)] = nextPO
+	} else {
+		delete(db.batchRadius, string(batchID))
+	}
+	db.batchRadiusMu.Unlock()
+
+	return movedCount, nil
+}
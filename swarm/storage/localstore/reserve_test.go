@@ -0,0 +1,136 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/swarm/shed"
+)
+
+// TestDB_evictReserve_byBatchValue uploads chunks under three
+// simulated postage batches of different values and asserts that
+// evictReserve always evicts from the lowest-value batch first,
+// moving its chunks into the cache (gc) tier rather than removing
+// them outright.
+func TestDB_evictReserve_byBatchValue(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity:        1000,
+		ReserveCapacity: 30,
+	})
+	defer cleanupFunc()
+
+	uploader := db.NewPutter(ModePutUpload)
+	syncer := db.NewSetter(ModeSetSync)
+
+	evictedChan := make(chan int64)
+	defer setTestHookEvictReserve(func(movedCount int64) {
+		evictedChan <- movedCount
+	})()
+
+	batches := []struct {
+		id    []byte
+		value uint64
+	}{
+		{id: []byte("low-value-batch"), value: 1},
+		{id: []byte("mid-value-batch"), value: 10},
+		{id: []byte("high-value-batch"), value: 100},
+	}
+
+	// farthestLowValueAddr is the low value batch chunk at PO 7, the
+	// farthest PO that batch holds when eviction is first triggered, so
+	// it is the first chunk evictReserve moves out of the reserve
+	var farthestLowValueAddr, firstHighValueAddr = "", ""
+
+	for i := 0; i < 15; i++ {
+		for _, b := range batches {
+			chunk := generateRandomChunk()
+			if err := uploader.Put(chunk); err != nil {
+				t.Fatal(err)
+			}
+			if err := syncer.Set(chunk.Address()); err != nil {
+				t.Fatal(err)
+			}
+			stamp := &PostageStamp{BatchID: b.id, Value: b.value, PO: uint8(i % 8)}
+			if err := uploader.PutWithStamp(chunk, stamp); err != nil {
+				t.Fatal(err)
+			}
+			if string(b.id) == "low-value-batch" && stamp.PO == 7 && farthestLowValueAddr == "" {
+				farthestLowValueAddr = string(chunk.Address())
+			}
+			if string(b.id) == "high-value-batch" && firstHighValueAddr == "" {
+				firstHighValueAddr = string(chunk.Address())
+			}
+		}
+	}
+
+	select {
+	case <-evictedChan:
+	case <-time.After(10 * time.Second):
+		t.Fatal("evict reserve timeout")
+	}
+
+	// the low value batch chunk should have been moved out of the
+	// reserve (postageIndex) and into the cache (gc) tier, while still
+	// being retrievable from the cache tier
+	if _, err := db.postageIndex.Get(shed.Item{BatchID: []byte("low-value-batch"), Address: []byte(farthestLowValueAddr)}); err != shed.ErrNotFound {
+		t.Fatalf("expected low value batch chunk to be evicted from the reserve, got err %v", err)
+	}
+	if !gcIndexHasAddress(t, db, []byte(farthestLowValueAddr)) {
+		t.Fatalf("expected evicted chunk to be present in the gc index")
+	}
+	if _, err := db.NewGetter(ModeGetRequest).Get([]byte(farthestLowValueAddr)); err != nil {
+		t.Fatalf("expected evicted chunk to still be retrievable from the cache tier: %v", err)
+	}
+
+	// the high value batch chunk should remain untouched in the reserve
+	if _, err := db.postageIndex.Get(shed.Item{BatchID: []byte("high-value-batch"), Address: []byte(firstHighValueAddr)}); err != nil {
+		t.Fatalf("expected high value batch chunk to remain in the reserve: %v", err)
+	}
+	if _, err := db.NewGetter(ModeGetRequest).Get([]byte(firstHighValueAddr)); err != nil {
+		t.Fatalf("expected high value batch chunk to remain available: %v", err)
+	}
+}
+
+// gcIndexHasAddress reports whether gcIndex holds an entry for addr.
+// gcIndex is keyed by AccessTimestamp|Address, so presence cannot be
+// checked with a direct Get and has to be found by iteration instead.
+func gcIndexHasAddress(t *testing.T, db *DB, addr []byte) bool {
+	t.Helper()
+	var found bool
+	err := db.gcIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		if string(item.Address) == string(addr) {
+			found = true
+			return true, nil
+		}
+		return false, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+// setTestHookEvictReserve sets testHookEvictReserve and returns a
+// function that will reset it to the value before the change.
+func setTestHookEvictReserve(h func(movedCount int64)) (reset func()) {
+	current := testHookEvictReserve
+	reset = func() { testHookEvictReserve = current }
+	testHookEvictReserve = h
+	return reset
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/swarm/storage"
+)
+
+// gcSubscriptionBufferSize is the number of GCEvents buffered per
+// subscriber before events start being dropped instead of blocking
+// the garbage collection loop. It is intentionally small: a
+// subscriber is expected to drain its channel promptly, and a slow
+// subscriber should start losing events rather than stall collection.
+const gcSubscriptionBufferSize = 16
+
+// GCReason identifies which eviction path produced a GCEvent.
+type GCReason int
+
+// GCEvent reasons.
+const (
+	// GCReasonCapacity is set when a chunk is removed by the regular
+	// LRU garbage collector because the cache tier is over capacity.
+	GCReasonCapacity GCReason = iota
+	// GCReasonReserve is set when a chunk is evicted out of the
+	// postage-aware reserve into the cache tier.
+	GCReasonReserve
+	// GCReasonManual is set when a chunk is removed as a result of a
+	// caller-triggered CollectGarbage call.
+	GCReasonManual
+)
+
+// GCEvent describes a single chunk affected by garbage collection.
+type GCEvent struct {
+	Address   storage.Address
+	Timestamp int64
+	Reason    GCReason
+	// Dropped is the number of GCEvents that were evicted from this
+	// subscriber's buffer to make room for this one, because the
+	// subscriber was not keeping up.
+	Dropped int64
+}
+
+// gcSubscription holds the per-subscriber channel and the count of
+// events dropped since the subscriber's buffer last had room.
+type gcSubscription struct {
+	events  chan GCEvent
+	dropped int64 // accessed atomically
+}
+
+// SubscribeGC returns a channel that receives a GCEvent for every
+// chunk collected by the garbage collector, for as long as ctx is not
+// done and the returned unsubscribe function has not been called.
+// Slow consumers do not block garbage collection: once a subscriber's
+// buffer is full, the oldest buffered event is evicted to make room,
+// and the number evicted is accounted for in the Dropped field of the
+// event that replaces it.
+func (db *DB) SubscribeGC(ctx context.Context) (c <-chan GCEvent, unsubscribe func()) {
+	events := make(chan GCEvent, gcSubscriptionBufferSize)
+	sub := &gcSubscription{events: events}
+
+	db.gcSubsMu.Lock()
+	id := db.gcSubsNextID
+	db.gcSubsNextID++
+	db.gcSubs[id] = sub
+	db.gcSubsMu.Unlock()
+
+	var stopped int32
+	unsubscribe = func() {
+		if !atomic.CompareAndSwapInt32(&stopped, 0, 1) {
+			return
+		}
+		db.gcSubsMu.Lock()
+		delete(db.gcSubs, id)
+		db.gcSubsMu.Unlock()
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			unsubscribe()
+		case <-db.close:
+		}
+	}()
+
+	return events, unsubscribe
+}
+
+// publishGCEvent fans out a GCEvent for addr to every active
+// subscriber without blocking: a subscriber whose buffer is full has
+// its oldest buffered event evicted to make room, so the event being
+// published now (which carries the resulting Dropped tally) gets
+// through instead of the tally being silently lost along with an event
+// that is never delivered. If a concurrent publisher fills the freed
+// slot first, the event is not delivered and the tally is kept for the
+// next one. publishGCEvent may be called
+// concurrently, by the background GC worker and by a caller-triggered
+// CollectGarbage, so the tally is cleared with a compare-and-swap
+// rather than an unconditional store: if another caller bumps it
+// concurrently, the CAS fails and the increment is preserved for the
+// next event instead of being erased.
+func (db *DB) publishGCEvent(addr storage.Address, reason GCReason) {
+	db.gcSubsMu.Lock()
+	subs := make([]*gcSubscription, 0, len(db.gcSubs))
+	for _, s := range db.gcSubs {
+		subs = append(subs, s)
+	}
+	db.gcSubsMu.Unlock()
+
+	if len(subs) == 0 {
+		return
+	}
+
+	for _, s := range subs {
+		event := GCEvent{Address: addr, Timestamp: now(), Reason: reason}
+
+		dropped := atomic.LoadInt64(&s.dropped)
+		event.Dropped = dropped
+		select {
+		case s.events <- event:
+			if dropped > 0 {
+				atomic.CompareAndSwapInt64(&s.dropped, dropped, 0)
+			}
+			continue
+		default:
+		}
+
+		// buffer full: evict the oldest buffered event to make room,
+		// then retry once with the updated tally so it is not lost.
+		select {
+		case <-s.events:
+		default:
+		}
+		dropped = atomic.AddInt64(&s.dropped, 1)
+		event.Dropped = dropped
+		select {
+		case s.events <- event:
+			atomic.CompareAndSwapInt64(&s.dropped, dropped, 0)
+		default:
+		}
+	}
+}